@@ -0,0 +1,24 @@
+package v1alpha1
+
+// Package condition types and reasons, surfaced on PackageStatus.Conditions
+// so operators can diagnose why a Package failed to reconcile without
+// reading controller logs.
+const (
+	// PackageInstalled indicates whether the package's Helm release has
+	// been successfully installed or upgraded.
+	PackageInstalled = "Installed"
+
+	// ReasonGetCACertificateFailed is set on PackageInstalled=False when the
+	// TLS trust material referenced by the package's chart source could not
+	// be resolved.
+	ReasonGetCACertificateFailed = "GetCACertificateFailed"
+
+	// ReasonReleaseRecoveryFailed is set on PackageInstalled=False when the
+	// driver could not recover a release stuck in a pending or failed
+	// state, per the package's UpgradePolicy.
+	ReasonReleaseRecoveryFailed = "ReleaseRecoveryFailed"
+
+	// ReasonAtomicRollback is set when an atomic upgrade failed and the
+	// driver rolled the release back to its prior deployed revision.
+	ReasonAtomicRollback = "AtomicRollback"
+)
@@ -0,0 +1,152 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LifecycleOptions configures how the driver installs and upgrades a
+// release, mirroring the ergonomics of `helm upgrade --atomic --wait`.
+type LifecycleOptions struct {
+	// Timeout bounds how long to wait for Kubernetes operations (including
+	// Wait and hooks) before giving up. Defaults to Helm's own default (5m)
+	// when unset.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Wait makes the install/upgrade block until all resources are in a
+	// ready state.
+	// +optional
+	Wait bool `json:"wait,omitempty"`
+
+	// WaitForJobs makes the install/upgrade also wait for Jobs to complete,
+	// when Wait is set.
+	// +optional
+	WaitForJobs bool `json:"waitForJobs,omitempty"`
+
+	// Atomic rolls back (on upgrade) or uninstalls (on install) the release
+	// if it fails, and implies Wait.
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+
+	// CleanupOnFail allows deleting newly-created resources during a failed
+	// upgrade's automatic rollback.
+	// +optional
+	CleanupOnFail bool `json:"cleanupOnFail,omitempty"`
+
+	// DisableHooks disables running any of the chart's Helm hooks.
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+}
+
+// PackageRepositorySource defines the location of a package's Helm chart in
+// a classic HTTP(S) chart repository (one serving an index.yaml), as an
+// alternative to sourcing it from an OCI registry.
+type PackageRepositorySource struct {
+	// URL is the chart repository's base URL, as passed to `helm repo add`.
+	URL string `json:"url"`
+
+	// Name is the local name to register the repository under.
+	Name string `json:"name"`
+
+	// ChartName is the name of the chart within the repository's index.
+	ChartName string `json:"chartName"`
+
+	// Version is the chart version to install.
+	Version string `json:"version,omitempty"`
+
+	// CredentialsRef references a Secret containing "username" and
+	// "password" keys used to authenticate with the repository.
+	// +optional
+	CredentialsRef string `json:"credentialsRef,omitempty"`
+
+	// PassCredentials passes repository credentials to all domains
+	// encountered during the chart download, including redirects.
+	// +optional
+	PassCredentials bool `json:"passCredentials,omitempty"`
+}
+
+// PackageSpec defines the desired state of a Package.
+type PackageSpec struct {
+	// PackageName is the name of the package to install.
+	PackageName string `json:"packageName,omitempty"`
+
+	// Source is the OCI registry location of the package's Helm chart. Set
+	// when the package comes from a curated OCI bundle rather than
+	// Repository.
+	// +optional
+	Source PackageOCISource `json:"source,omitempty"`
+
+	// Repository sources the package's Helm chart from a classic HTTP(S)
+	// chart repository instead of an OCI registry.
+	// +optional
+	Repository *PackageRepositorySource `json:"repository,omitempty"`
+
+	// Config holds the Helm values to install or upgrade the chart with.
+	// +optional
+	Config string `json:"config,omitempty"`
+
+	// TargetNamespace is the namespace to install the package into.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// UpgradePolicy controls how the driver recovers a release that is
+	// stuck in a pending-install, pending-upgrade, or failed state, which
+	// Helm otherwise refuses to upgrade from. Defaults to
+	// RollbackOnFailure.
+	// +optional
+	// +kubebuilder:validation:Enum=RollbackOnFailure;ReinstallOnFailure;Fail
+	UpgradePolicy string `json:"upgradePolicy,omitempty"`
+
+	// Lifecycle configures timeout, wait, and atomic install/upgrade
+	// behavior.
+	// +optional
+	Lifecycle LifecycleOptions `json:"lifecycle,omitempty"`
+}
+
+const (
+	// UpgradePolicyRollbackOnFailure rolls a stuck release back to its last
+	// deployed revision before retrying the upgrade.
+	UpgradePolicyRollbackOnFailure = "RollbackOnFailure"
+
+	// UpgradePolicyReinstallOnFailure uninstalls and reinstalls a stuck
+	// release from scratch.
+	UpgradePolicyReinstallOnFailure = "ReinstallOnFailure"
+
+	// UpgradePolicyFail leaves a stuck release untouched and fails
+	// reconciliation, requiring an operator to intervene.
+	UpgradePolicyFail = "Fail"
+)
+
+// PackageStatus defines the observed state of a Package.
+type PackageStatus struct {
+	// Conditions holds the latest observations of the Package's state.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Source reflects the chart source currently installed, either "oci" or
+	// "repository".
+	// +optional
+	Source string `json:"source,omitempty"`
+
+	// ReleaseStatus mirrors the underlying Helm release's status (e.g.
+	// deployed, failed, pending-upgrade), so operators can see why
+	// reconciliation is blocked without inspecting Helm's release secrets
+	// directly.
+	// +optional
+	ReleaseStatus string `json:"releaseStatus,omitempty"`
+
+	// ConfigDiffSummary is a short human-readable summary of the most
+	// recently observed drift between the deployed release and the
+	// rendered desired state, e.g. "1 added, 0 removed, 3 changed".
+	// +optional
+	ConfigDiffSummary string `json:"configDiffSummary,omitempty"`
+}
+
+// Package is the Schema for the packages API.
+type Package struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PackageSpec   `json:"spec,omitempty"`
+	Status PackageStatus `json:"status,omitempty"`
+}
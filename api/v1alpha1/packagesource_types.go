@@ -0,0 +1,59 @@
+package v1alpha1
+
+import "fmt"
+
+// PackageOCISource defines the location of a package's Helm chart in an OCI
+// or HTTPS registry.
+type PackageOCISource struct {
+	// Registry is the OCI registry or HTTPS host serving the chart, e.g.
+	// "public.ecr.aws/eks-anywhere".
+	Registry string `json:"registry,omitempty"`
+
+	// Repository is the chart's repository path within Registry.
+	Repository string `json:"repository,omitempty"`
+
+	// Version is the chart version to install.
+	Version string `json:"version,omitempty"`
+
+	// Digest is the content digest of the chart, used to pin installs to an
+	// immutable artifact.
+	Digest string `json:"digest,omitempty"`
+
+	// TLS holds the trust configuration used when pulling the chart from
+	// Registry. When unset, the driver falls back to the ambient system
+	// trust store.
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// TLSConfig configures the TLS trust material used to connect to a chart
+// registry, sourced from Secrets in the cluster the package is reconciled
+// from.
+type TLSConfig struct {
+	// CASecretRef references a Secret containing the CA bundle to trust,
+	// under the key "ca.crt".
+	// +optional
+	CASecretRef string `json:"caSecretRef,omitempty"`
+
+	// CertSecretRef references a Secret containing a client certificate and
+	// key, under the keys "tls.crt" and "tls.key", for mutual TLS.
+	// +optional
+	CertSecretRef string `json:"certSecretRef,omitempty"`
+
+	// InsecureSkipVerify disables verification of the registry's
+	// certificate chain and hostname. This should only be used for testing.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// GetChartUri returns the fully-qualified chart URI for this source,
+// constructed from its Registry and Repository.
+func (s PackageOCISource) GetChartUri() string {
+	return fmt.Sprintf("oci://%s/%s", s.Registry, s.Repository)
+}
+
+// HasTLSConfig reports whether a TLS trust configuration was given for this
+// source.
+func (s PackageOCISource) HasTLSConfig() bool {
+	return s.TLS != nil
+}
@@ -0,0 +1,38 @@
+package authenticator
+
+import (
+	"context"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// Authenticator resolves registry credentials and secret material on behalf
+// of the helm driver, and keeps track of which namespaces packages have been
+// installed into so imagePullSecrets can be kept in sync across the
+// cluster.
+type Authenticator interface {
+	// AuthFilename returns the path to the docker-style config file used to
+	// authenticate with OCI registries.
+	AuthFilename() string
+
+	// GetSecretValues returns the Helm values (e.g. imagePullSecrets) derived
+	// from the registry credentials available to namespace.
+	GetSecretValues(ctx context.Context, namespace string) (map[string]interface{}, error)
+
+	// GetSecretData returns the raw data of the named Secret in namespace,
+	// keyed by the Secret's data keys.
+	GetSecretData(ctx context.Context, namespace string, secretName string) (map[string][]byte, error)
+
+	AddToConfigMap(ctx context.Context, name string, namespace string) error
+	AddSecretToAllNamespace(ctx context.Context) error
+	DelFromConfigMap(ctx context.Context, name string, namespace string) error
+}
+
+// TargetClusterClient provides access to the target cluster a package is
+// being reconciled into, for both kubectl-style REST access and
+// driver-specific initialization.
+type TargetClusterClient interface {
+	genericclioptions.RESTClientGetter
+
+	Initialize(ctx context.Context, clusterName string) error
+}
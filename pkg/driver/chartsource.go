@@ -0,0 +1,124 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+
+	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
+)
+
+// getterAll returns the set of scheme getters (http, https, oci) helm uses
+// to fetch repository indexes and charts.
+func getterAll(settings *cli.EnvSettings) getter.Providers {
+	return getter.All(settings)
+}
+
+// ChartSource resolves and loads the Helm chart for a package, configuring
+// install with whatever ChartPathOptions (version, repo credentials, TLS,
+// ...) it needs along the way. Implementations exist for OCI registries and
+// classic HTTP(S) chart repositories, so the driver itself stays agnostic
+// to where a chart actually comes from.
+type ChartSource interface {
+	LocateChart(ctx context.Context, d *helmDriver, namespace string, install *action.Install) (*chart.Chart, error)
+}
+
+// OCIChartSource loads a chart published to an OCI (or plain HTTPS)
+// registry, as described by an api.PackageOCISource. This is the original,
+// and still default, way packages are sourced.
+type OCIChartSource struct {
+	Source api.PackageOCISource
+}
+
+var _ ChartSource = (*OCIChartSource)(nil)
+
+func (s *OCIChartSource) LocateChart(ctx context.Context, d *helmDriver, namespace string, install *action.Install) (*chart.Chart, error) {
+	install.Version = s.Source.Version
+
+	tlsCfg, err := d.resolveTLSConfig(ctx, namespace, s.Source)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		install.CaFile = tlsCfg.caFile
+		install.CertFile = tlsCfg.certFile
+		install.KeyFile = tlsCfg.keyFile
+		install.InsecureSkipTLSverify = tlsCfg.insecureSkipVerify
+	}
+
+	return d.getChart(install, s.Source, tlsCfg)
+}
+
+// HTTPRepoChartSource loads a chart from a classic Helm chart repository
+// (one serving an index.yaml), as described by an
+// api.PackageRepositorySource.
+type HTTPRepoChartSource struct {
+	Source api.PackageRepositorySource
+}
+
+var _ ChartSource = (*HTTPRepoChartSource)(nil)
+
+func (s *HTTPRepoChartSource) LocateChart(ctx context.Context, d *helmDriver, namespace string, install *action.Install) (*chart.Chart, error) {
+	entry := repo.Entry{
+		Name:               s.Source.Name,
+		URL:                s.Source.URL,
+		PassCredentialsAll: s.Source.PassCredentials,
+	}
+
+	if s.Source.CredentialsRef != "" {
+		data, err := d.secretAuth.GetSecretData(ctx, namespace, s.Source.CredentialsRef)
+		if err != nil {
+			return nil, fmt.Errorf("getting repository credentials %s: %w", s.Source.CredentialsRef, err)
+		}
+		entry.Username = string(data["username"])
+		entry.Password = string(data["password"])
+	}
+
+	if err := d.addOrUpdateRepo(entry); err != nil {
+		return nil, fmt.Errorf("adding chart repository %s: %w", entry.Name, err)
+	}
+
+	install.Version = s.Source.Version
+	install.RepoURL = entry.URL
+	install.Username = entry.Username
+	install.Password = entry.Password
+	install.PassCredentialsAll = entry.PassCredentialsAll
+
+	chartPath, err := install.LocateChart(s.Source.ChartName, d.settings)
+	if err != nil {
+		return nil, fmt.Errorf("locating helm chart %s in repository %s: %w", s.Source.ChartName, entry.Name, err)
+	}
+	return loader.Load(chartPath)
+}
+
+// addOrUpdateRepo registers entry in the driver's repository.yaml (the
+// `helm repo add` / `helm repo update` equivalent) and refreshes its
+// index.yaml so LocateChart can resolve ChartName against current state.
+func (d *helmDriver) addOrUpdateRepo(entry repo.Entry) error {
+	chartRepo, err := repo.NewChartRepository(&entry, getterAll(d.settings))
+	if err != nil {
+		return fmt.Errorf("creating chart repository client: %w", err)
+	}
+	chartRepo.CachePath = d.settings.RepositoryCache
+
+	if _, err := chartRepo.DownloadIndexFile(); err != nil {
+		return fmt.Errorf("fetching index.yaml: %w", err)
+	}
+
+	repoFile, err := repo.LoadFile(d.settings.RepositoryConfig)
+	if err != nil {
+		repoFile = repo.NewFile()
+	}
+	repoFile.Update(&entry)
+
+	if err := repoFile.WriteFile(d.settings.RepositoryConfig, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", d.settings.RepositoryConfig, err)
+	}
+	return nil
+}
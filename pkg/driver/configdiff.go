@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ResourceRef identifies a rendered manifest by group-version-kind,
+// namespace, and name.
+type ResourceRef struct {
+	GVK       string
+	Namespace string
+	Name      string
+}
+
+// ConfigDiff describes how a release's currently-deployed manifest differs
+// from what re-rendering the chart with a candidate set of values would
+// produce.
+type ConfigDiff struct {
+	Added   []ResourceRef
+	Removed []ResourceRef
+	Changed []ResourceRef
+}
+
+// HasChanges reports whether the diff contains any added, removed, or
+// changed resources.
+func (d ConfigDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// Summary returns a short human-readable count of the diff's contents,
+// suitable for surfacing on the Package status.
+func (d ConfigDiff) Summary() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// IsConfigChanged reports whether values would produce a different set of
+// rendered resources than the currently-deployed release of name, by
+// dry-run re-rendering the chart at source's version with values merged in
+// and diffing the result against the last deployed manifest. This catches
+// drift from chart-version bumps, computed defaults, and in-cluster
+// mutations that a plain comparison of input values against rel.Config
+// would miss.
+//
+// opts must match the InstallOptions the release was last installed or
+// upgraded with, in particular ImagePullSecret and RegistryRewrites: the
+// deployed manifest was stored after the post-renderer injected those, so
+// the dry-run render has to apply the same post-renderer or every
+// PodSpec-bearing resource would spuriously diff as Changed.
+func (d *helmDriver) IsConfigChanged(ctx context.Context, name string, namespace string,
+	source ChartSource, values map[string]interface{}, opts InstallOptions) (bool, *ConfigDiff, error) {
+	rel, err := d.cfg.Releases.Deployed(name)
+	if err != nil {
+		return false, nil, fmt.Errorf("getting deployed helm release %s: %w", name, err)
+	}
+
+	install := action.NewInstall(d.cfg)
+	install.ReleaseName = name
+	install.Namespace = namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.PostRenderer = newClusterTransformPostRenderer(opts.ImagePullSecret, opts.RegistryRewrites)
+
+	helmChart, err := source.LocateChart(ctx, d, namespace, install)
+	if err != nil {
+		return false, nil, fmt.Errorf("loading helm chart %s: %w", name, err)
+	}
+
+	// Resolve an empty namespace the same way Install does, so the secret
+	// values merged below are those the release was installed with.
+	if namespace == "" {
+		namespace = "default"
+		if chartNS, ok := helmChart.Values["defaultNamespace"].(string); ok {
+			namespace = chartNS
+		}
+	}
+
+	// The deployed release was installed with the auth secret-derived
+	// values (e.g. imagePullSecrets) merged in; merge the same values here
+	// so the dry-run render matches instead of spuriously diffing every
+	// resource that references one of those keys as Changed.
+	d.mergeSecretValues(ctx, namespace, values)
+
+	rendered, err := install.RunWithContext(ctx, helmChart, values)
+	if err != nil {
+		return false, nil, fmt.Errorf("dry-run rendering helm chart %s: %w", name, err)
+	}
+
+	diff, err := diffManifests(rel.Manifest, rendered.Manifest)
+	if err != nil {
+		return false, nil, fmt.Errorf("diffing rendered manifests for %s: %w", name, err)
+	}
+
+	return diff.HasChanges(), diff, nil
+}
+
+// diffManifests compares the resources in the current and desired
+// multi-document YAML manifests, keyed by GVK+namespace+name, after
+// normalizing each document so that map key ordering and numeric type
+// coercion don't register as spurious changes.
+func diffManifests(current, desired string) (*ConfigDiff, error) {
+	currentResources, err := splitManifest(current)
+	if err != nil {
+		return nil, fmt.Errorf("parsing current manifest: %w", err)
+	}
+	desiredResources, err := splitManifest(desired)
+	if err != nil {
+		return nil, fmt.Errorf("parsing desired manifest: %w", err)
+	}
+
+	diff := &ConfigDiff{}
+	for ref, desiredDoc := range desiredResources {
+		currentDoc, ok := currentResources[ref]
+		if !ok {
+			diff.Added = append(diff.Added, ref)
+			continue
+		}
+		if currentDoc != desiredDoc {
+			diff.Changed = append(diff.Changed, ref)
+		}
+	}
+	for ref := range currentResources {
+		if _, ok := desiredResources[ref]; !ok {
+			diff.Removed = append(diff.Removed, ref)
+		}
+	}
+
+	return diff, nil
+}
+
+// splitManifest parses a multi-document Helm manifest into a map of
+// normalized, canonically-marshaled YAML documents keyed by ResourceRef.
+func splitManifest(manifest string) (map[ResourceRef]string, error) {
+	resources := map[ResourceRef]string{}
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: obj}
+		ref := ResourceRef{
+			GVK:       u.GetAPIVersion() + "/" + u.GetKind(),
+			Namespace: u.GetNamespace(),
+			Name:      u.GetName(),
+		}
+
+		normalized, err := sigsyaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("normalizing %s %s: %w", ref.GVK, ref.Name, err)
+		}
+		resources[ref] = string(bytes.TrimSpace(normalized))
+	}
+
+	return resources, nil
+}
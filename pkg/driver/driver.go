@@ -0,0 +1,92 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
+)
+
+// PackageDriver installs, upgrades, and removes packages on a target
+// cluster.
+type PackageDriver interface {
+	// Initialize prepares the driver to operate against clusterName,
+	// establishing any cluster client state it needs.
+	Initialize(ctx context.Context, clusterName string) error
+
+	// Install installs or upgrades the release called name in namespace
+	// from the chart resolved by source, per opts. The returned
+	// InstallResult reports what was observed and done even when err is
+	// non-nil (e.g. a recovery action taken before a failed upgrade), so a
+	// reconciler can mirror it onto the Package's status.
+	Install(ctx context.Context, name string, namespace string, source ChartSource, values map[string]interface{}, opts InstallOptions) (InstallResult, error)
+
+	// Uninstall removes the release called name.
+	Uninstall(ctx context.Context, name string) error
+
+	// IsConfigChanged reports whether values would produce a different set
+	// of rendered resources than the currently-deployed release of name,
+	// along with a structured diff the caller can surface for
+	// observability. opts must match the InstallOptions the release is
+	// installed/upgraded with, so the dry-run render applies the same
+	// post-renderer the deployed manifest was stored with.
+	IsConfigChanged(ctx context.Context, name string, namespace string, source ChartSource, values map[string]interface{}, opts InstallOptions) (bool, *ConfigDiff, error)
+}
+
+// InstallResult reports what Install observed and did, so a reconciler can
+// mirror it onto the Package's status (ReleaseStatus, and a recovery event)
+// without re-querying Helm itself.
+type InstallResult struct {
+	// ReleaseStatus is the underlying Helm release's status after Install
+	// returns, e.g. "deployed", "failed", or "pending-upgrade". Empty if the
+	// release could not be found at all.
+	ReleaseStatus string
+
+	// RecoveryAction is set when a release stuck in a pending or failed
+	// state was recovered before upgrading, one of the RecoveryAction*
+	// constants. Empty when no recovery was needed.
+	RecoveryAction string
+}
+
+// RecoveryAction* enumerates the actions recoverReleaseIfStuck can take.
+const (
+	RecoveryActionRollback  = "rollback"
+	RecoveryActionReinstall = "reinstall"
+)
+
+// InstallOptions carries the per-Package knobs that shape how the driver
+// installs or upgrades a release, beyond the chart and values themselves.
+type InstallOptions struct {
+	// UpgradePolicy controls how a release stuck in a pending or failed
+	// state is recovered before upgrading. One of the UpgradePolicy*
+	// constants; defaults to UpgradePolicyRollbackOnFailure when empty.
+	UpgradePolicy string
+
+	// ImagePullSecret, when set, is appended via a post-renderer to the
+	// PodSpec of every Deployment, StatefulSet, DaemonSet, Job, CronJob, and
+	// Pod in the rendered manifests, rather than relying on the chart
+	// honoring an imagePullSecrets value.
+	ImagePullSecret string
+
+	// RegistryRewrites rewrites container images whose reference matches
+	// RegistryRewrite.From to RegistryRewrite.To, e.g. to route through an
+	// air-gapped cluster's local mirror.
+	RegistryRewrites []RegistryRewrite
+
+	// Lifecycle configures timeout, wait, and atomic install/upgrade
+	// behavior.
+	Lifecycle api.LifecycleOptions
+}
+
+// ChartSourceFor builds the ChartSource described by spec: a
+// PackageRepositorySource when spec.Repository is set, otherwise the
+// PackageOCISource in spec.Source.
+func ChartSourceFor(spec api.PackageSpec) (ChartSource, error) {
+	if spec.Repository != nil {
+		return &HTTPRepoChartSource{Source: *spec.Repository}, nil
+	}
+	if spec.Source.Registry == "" {
+		return nil, fmt.Errorf("package spec has neither source nor repository configured")
+	}
+	return &OCIChartSource{Source: spec.Source}, nil
+}
@@ -4,17 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/go-logr/logr"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	tlsutil "helm.sh/helm/v3/pkg/tlsutil"
 
 	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
 	auth "github.com/aws/eks-anywhere-packages/pkg/authenticator"
@@ -24,6 +27,43 @@ const (
 	varHelmUpgradeMaxHistory = 2
 )
 
+// ErrGetCACertificateFailed is returned when the TLS trust material
+// referenced by a PackageOCISource could not be resolved. Reconcilers
+// should surface this as a GetCACertificateFailed condition on the
+// Package so operators can diagnose registry trust problems, rather than
+// letting the chart pull fail opaquely.
+var ErrGetCACertificateFailed = errors.New("getting CA certificate")
+
+// ErrReleaseRecoveryFailed is returned when a release stuck in a pending or
+// failed state could not be recovered per its UpgradePolicy. Reconcilers
+// should surface this as a ReleaseRecoveryFailed condition on the Package.
+var ErrReleaseRecoveryFailed = errors.New("recovering release")
+
+// ErrAtomicRollback is returned when an atomic upgrade failed and the
+// driver rolled the release back to its prior deployed revision. It wraps
+// the original upgrade error. Reconcilers should surface this as an
+// AtomicRollback event/condition rather than treating it as a bare
+// failure, since the release itself was left in a healthy state.
+var ErrAtomicRollback = errors.New("atomic upgrade failed, rolled back")
+
+// ReasonFor maps an error returned by Install or IsConfigChanged to the
+// PackageInstalled condition reason a reconciler should set alongside it
+// (one of the api.Reason* constants), so that mapping lives in one place
+// instead of being re-derived with errors.Is chains at every call site.
+// Returns "" for errors that don't correspond to one of the known reasons.
+func ReasonFor(err error) string {
+	switch {
+	case errors.Is(err, ErrGetCACertificateFailed):
+		return api.ReasonGetCACertificateFailed
+	case errors.Is(err, ErrAtomicRollback):
+		return api.ReasonAtomicRollback
+	case errors.Is(err, ErrReleaseRecoveryFailed):
+		return api.ReasonReleaseRecoveryFailed
+	default:
+		return ""
+	}
+}
+
 // helmDriver implements PackageDriver to install packages from Helm charts.
 type helmDriver struct {
 	cfg        *action.Configuration
@@ -31,6 +71,16 @@ type helmDriver struct {
 	tcc        auth.TargetClusterClient
 	log        logr.Logger
 	settings   *cli.EnvSettings
+
+	// tlsCache holds the materialized TLS trust material resolved by
+	// resolveTLSConfig, keyed by namespace and secret refs, so repeated
+	// resolutions of the same source (e.g. from IsConfigChanged's dry-run
+	// renders) reuse the same temp files instead of leaking a new one each
+	// time. Entries also track the content hash of the Secrets they were
+	// built from, so a rotated CA/cert is detected and re-resolved instead
+	// of trusting stale material for the process lifetime.
+	tlsCacheMu sync.Mutex
+	tlsCache   map[string]*cachedTLS
 }
 
 var _ PackageDriver = (*helmDriver)(nil)
@@ -66,16 +116,17 @@ func (d *helmDriver) Initialize(ctx context.Context, clusterName string) (err er
 }
 
 func (d *helmDriver) Install(ctx context.Context,
-	name string, namespace string, source api.PackageOCISource, values map[string]interface{}) error {
+	name string, namespace string, source ChartSource, values map[string]interface{}, opts InstallOptions) (InstallResult, error) {
 	var err error
 	install := action.NewInstall(d.cfg)
-	install.Version = source.Version
 	install.ReleaseName = name
 	install.Namespace = namespace
+	install.PostRenderer = newClusterTransformPostRenderer(opts.ImagePullSecret, opts.RegistryRewrites)
+	applyInstallLifecycle(install, opts.Lifecycle)
 
-	helmChart, err := d.getChart(install, source)
+	helmChart, err := source.LocateChart(ctx, d, namespace, install)
 	if err != nil {
-		return fmt.Errorf("loading helm chart %s: %w", name, err)
+		return InstallResult{}, fmt.Errorf("loading helm chart %s: %w", name, err)
 	}
 	// If no target namespace provided read chart values to find namespace
 	if namespace == "" {
@@ -87,16 +138,7 @@ func (d *helmDriver) Install(ctx context.Context,
 		}
 	}
 
-	// Update values with imagePullSecrets
-	// If no secret values we should still continue as it could be case of public registry or local registry
-	secretvals, err := d.secretAuth.GetSecretValues(ctx, namespace)
-	if err != nil {
-		secretvals = nil
-		// Continue as its possible that a private registry is being used here and thus no data necessary
-	}
-	for key, val := range secretvals {
-		values[key] = val
-	}
+	d.mergeSecretValues(ctx, namespace, values)
 
 	// Check if there exists a matching helm release.
 	get := action.NewGet(d.cfg)
@@ -104,8 +146,9 @@ func (d *helmDriver) Install(ctx context.Context,
 	if err != nil {
 		if errors.Is(err, driver.ErrReleaseNotFound) {
 			err = d.createRelease(ctx, install, helmChart, values)
+			result := InstallResult{ReleaseStatus: d.observedStatus(name)}
 			if err != nil {
-				return err
+				return result, err
 			}
 			if err := d.secretAuth.AddToConfigMap(ctx, name, namespace); err != nil {
 				d.log.Info("failed to Update ConfigMap with installed namespace")
@@ -113,30 +156,69 @@ func (d *helmDriver) Install(ctx context.Context,
 			if err := d.secretAuth.AddSecretToAllNamespace(ctx); err != nil {
 				d.log.Info("Failed to Update Secret in all namespaces")
 			}
-			return nil
+			return result, nil
 		}
-		return fmt.Errorf("getting helm release %s: %w", name, err)
+		return InstallResult{}, fmt.Errorf("getting helm release %s: %w", name, err)
 	}
 
-	err = d.upgradeRelease(ctx, name, helmChart, values)
+	result, err := d.upgradeRelease(ctx, name, namespace, helmChart, values, install.PostRenderer, opts)
 	if err != nil {
-		return fmt.Errorf("upgrading helm chart %s: %w", name, err)
+		return result, fmt.Errorf("upgrading helm chart %s: %w", name, err)
 	}
 
 	// Update installed-namespaces on successful install
-	err = d.secretAuth.AddToConfigMap(ctx, name, namespace)
-	if err != nil {
+	if err := d.secretAuth.AddToConfigMap(ctx, name, namespace); err != nil {
 		d.log.Info("failed to Update ConfigMap with installed namespace")
 	}
 	if err := d.secretAuth.AddSecretToAllNamespace(ctx); err != nil {
 		d.log.Info("Failed to Update Secret in all namespaces")
 	}
 
-	return nil
+	return result, nil
 }
 
-func (d *helmDriver) getChart(install *action.Install, source api.PackageOCISource) (*chart.Chart, error) {
+// observedStatus best-effort looks up the current Helm release status for
+// name, e.g. "deployed" or "failed", for InstallResult.ReleaseStatus.
+// Returns "" if the release can't be found.
+func (d *helmDriver) observedStatus(name string) string {
+	status := action.NewStatus(d.cfg)
+	rel, err := status.Run(name)
+	if err != nil {
+		return ""
+	}
+	return string(rel.Info.Status)
+}
+
+// mergeSecretValues adds the auth secret-derived Helm values (e.g.
+// imagePullSecrets) for namespace into values in place. A lookup failure is
+// not treated as an error: it's possible a private registry isn't in use, so
+// there's simply no data to merge.
+//
+// Both Install and IsConfigChanged must call this with the same namespace
+// before rendering, since the deployed release was installed with these
+// values merged in; skipping it on the dry-run side would make every chart
+// that renders one of these keys diff as changed on every reconcile.
+func (d *helmDriver) mergeSecretValues(ctx context.Context, namespace string, values map[string]interface{}) {
+	secretvals, err := d.secretAuth.GetSecretValues(ctx, namespace)
+	if err != nil {
+		return
+	}
+	for key, val := range secretvals {
+		values[key] = val
+	}
+}
+
+func (d *helmDriver) getChart(install *action.Install, source api.PackageOCISource, tlsCfg *chartRegistryTLS) (*chart.Chart, error) {
 	url := source.GetChartUri()
+
+	if tlsCfg != nil {
+		registryClient, err := newRegistryClient(d.secretAuth.AuthFilename(), tlsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: building registry client: %v", ErrGetCACertificateFailed, err)
+		}
+		install.SetRegistryClient(registryClient)
+	}
+
 	chartPath, err := install.LocateChart(url, d.settings)
 	if err != nil {
 		return nil, fmt.Errorf("locating helm chart %s tag %s: %w", url, source.Digest, err)
@@ -144,6 +226,27 @@ func (d *helmDriver) getChart(install *action.Install, source api.PackageOCISour
 	return loader.Load(chartPath)
 }
 
+// newRegistryClient builds a Helm OCI registry client that trusts the CA
+// bundle and, if configured, presents the client certificate resolved onto
+// tlsCfg, falling back to the ambient system trust store for anything not
+// explicitly configured.
+func newRegistryClient(authorizationFileName string, tlsCfg *chartRegistryTLS) (*registry.Client, error) {
+	opts := []registry.ClientOption{registry.ClientOptCredentialsFile(authorizationFileName)}
+
+	if tlsCfg.caFile != "" || tlsCfg.certFile != "" || tlsCfg.insecureSkipVerify {
+		tlsConf, err := tlsutil.NewClientTLS(tlsCfg.certFile, tlsCfg.keyFile, tlsCfg.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("building TLS config: %w", err)
+		}
+		tlsConf.InsecureSkipVerify = tlsCfg.insecureSkipVerify
+		opts = append(opts, registry.ClientOptHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConf},
+		}))
+	}
+
+	return registry.NewClient(opts...)
+}
+
 func (d *helmDriver) createRelease(ctx context.Context,
 	install *action.Install, helmChart *chart.Chart, values map[string]interface{}) error {
 	_, err := install.RunWithContext(ctx, helmChart, values)
@@ -162,21 +265,45 @@ func helmChartURLIsPrefixed(url string) bool {
 		strings.HasPrefix(url, "oci://")
 }
 
-// upgradeRelease instructs helm to upgrade a release.
-func (d *helmDriver) upgradeRelease(ctx context.Context, name string,
-	helmChart *chart.Chart, values map[string]interface{}) (err error) {
+// upgradeRelease instructs helm to upgrade a release. The returned
+// InstallResult is populated even on error, so a failed recovery or upgrade
+// still reports whatever was observed and done.
+func (d *helmDriver) upgradeRelease(ctx context.Context, name string, namespace string,
+	helmChart *chart.Chart, values map[string]interface{}, postRenderer postrender.PostRenderer, opts InstallOptions) (InstallResult, error) {
+
+	recoveryAction, err := d.recoverReleaseIfStuck(ctx, name, namespace, helmChart, values, opts)
+	if err != nil {
+		return InstallResult{RecoveryAction: recoveryAction, ReleaseStatus: d.observedStatus(name)},
+			fmt.Errorf("%w: %v", ErrReleaseRecoveryFailed, err)
+	}
+	if recoveryAction == RecoveryActionReinstall {
+		// recoverReleaseIfStuck already reinstalled the release from
+		// helmChart and values, so running an upgrade here would be a
+		// redundant second action against the release it just created.
+		return InstallResult{RecoveryAction: recoveryAction, ReleaseStatus: d.observedStatus(name)}, nil
+	}
 
 	// upgrade unless changes in the values are detected. For POC, run helm
 	// every time and rely on its idempotency.
 	upgrade := action.NewUpgrade(d.cfg)
 	// Limit history saved as secret for resource limit
 	upgrade.MaxHistory = varHelmUpgradeMaxHistory
+	upgrade.PostRenderer = postRenderer
+	applyUpgradeLifecycle(upgrade, opts.Lifecycle)
 	_, err = upgrade.RunWithContext(ctx, name, helmChart, values)
+	result := InstallResult{RecoveryAction: recoveryAction, ReleaseStatus: d.observedStatus(name)}
 	if err != nil {
-		return fmt.Errorf("upgrading helm release %s: %w", name, err)
+		if upgrade.Atomic {
+			// Helm itself already rolled the release back to its prior
+			// deployed revision before returning; wrap the error so
+			// reconcilers can tell the two apart and record the rollback
+			// as an event instead of a bare failure.
+			return result, fmt.Errorf("%w: upgrading helm release %s: %v", ErrAtomicRollback, name, err)
+		}
+		return result, fmt.Errorf("upgrading helm release %s: %w", name, err)
 	}
 
-	return nil
+	return result, nil
 }
 
 func (d *helmDriver) Uninstall(ctx context.Context, name string) (err error) {
@@ -201,21 +328,3 @@ func helmLog(log logr.Logger) action.DebugLog {
 		log.Info(fmt.Sprintf(template, args...))
 	}
 }
-
-func (d *helmDriver) IsConfigChanged(_ context.Context, name string, values map[string]interface{}) (bool, error) {
-	get := action.NewGet(d.cfg)
-	rel, err := get.Run(name)
-	if err != nil {
-		return false, fmt.Errorf("installation not found %q: %w", name, err)
-	}
-
-	// Check imagePullSecret not defined in config
-	if _, exist := values["imagePullSecrets"]; !exist {
-		// Check if imagePullSecrets was added by driver
-		if val, ok := rel.Config["imagePullSecrets"]; ok {
-			values["imagePullSecrets"] = val
-		}
-	}
-
-	return !reflect.DeepEqual(values, rel.Config), nil
-}
@@ -0,0 +1,42 @@
+package driver
+
+import (
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+
+	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
+)
+
+// defaultLifecycleTimeout is used when a package sets no Timeout, matching
+// the Helm CLI's own default.
+const defaultLifecycleTimeout = 5 * time.Minute
+
+// applyInstallLifecycle copies the package's lifecycle options onto a Helm
+// install action. Atomic implies Wait, matching `helm install --atomic`.
+func applyInstallLifecycle(install *action.Install, opts api.LifecycleOptions) {
+	install.Timeout = defaultLifecycleTimeout
+	if opts.Timeout != nil {
+		install.Timeout = opts.Timeout.Duration
+	}
+	install.Wait = opts.Wait || opts.Atomic
+	install.WaitForJobs = opts.WaitForJobs
+	install.Atomic = opts.Atomic
+	install.DisableHooks = opts.DisableHooks
+}
+
+// applyUpgradeLifecycle copies the package's lifecycle options onto a Helm
+// upgrade action. Atomic implies Wait, matching `helm upgrade --atomic`, so
+// Helm itself rolls a failed upgrade back; upgradeRelease then wraps the
+// error in ErrAtomicRollback so the event is visible on the Package status.
+func applyUpgradeLifecycle(upgrade *action.Upgrade, opts api.LifecycleOptions) {
+	upgrade.Timeout = defaultLifecycleTimeout
+	if opts.Timeout != nil {
+		upgrade.Timeout = opts.Timeout.Duration
+	}
+	upgrade.Wait = opts.Wait || opts.Atomic
+	upgrade.WaitForJobs = opts.WaitForJobs
+	upgrade.Atomic = opts.Atomic
+	upgrade.CleanupOnFail = opts.CleanupOnFail
+	upgrade.DisableHooks = opts.DisableHooks
+}
@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// RegistryRewrite rewrites images pulled from From to use To instead,
+// e.g. to route through an air-gapped cluster's local mirror.
+type RegistryRewrite struct {
+	From string
+	To   string
+}
+
+// clusterTransformPostRenderer implements helm's postrender.PostRenderer to
+// inject an imagePullSecret and apply registry mirror rewrites into every
+// resource of a Kind listed in podSpecPath in a chart's rendered manifests,
+// so curated charts don't each need to be authored with registry awareness
+// baked in. Other Pod-creating Kinds, such as ReplicaSet, are not changed.
+type clusterTransformPostRenderer struct {
+	imagePullSecret  string
+	registryRewrites []RegistryRewrite
+}
+
+var _ postrender.PostRenderer = (*clusterTransformPostRenderer)(nil)
+
+// newClusterTransformPostRenderer returns a PostRenderer that appends
+// imagePullSecret (when non-empty) to every PodSpec it finds, and rewrites
+// image references per registryRewrites. Returns nil when there is nothing
+// to do, so callers can skip wiring a post-renderer into the action at all.
+func newClusterTransformPostRenderer(imagePullSecret string, registryRewrites []RegistryRewrite) postrender.PostRenderer {
+	if imagePullSecret == "" && len(registryRewrites) == 0 {
+		return nil
+	}
+	return &clusterTransformPostRenderer{
+		imagePullSecret:  imagePullSecret,
+		registryRewrites: registryRewrites,
+	}
+}
+
+// podSpecPath locates the PodSpec within a resource's unstructured content,
+// which varies by Kind.
+var podSpecPath = map[string][]string{
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"Pod":         {"spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// Run implements postrender.PostRenderer.
+func (p *clusterTransformPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	out := &bytes.Buffer{}
+	decoder := yaml.NewYAMLOrJSONDecoder(renderedManifests, 4096)
+
+	first := true
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decoding rendered manifest: %w", err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: obj}
+		if path, ok := podSpecPath[u.GetKind()]; ok {
+			if err := p.transformPodSpec(u, path); err != nil {
+				return nil, fmt.Errorf("transforming %s %s: %w", u.GetKind(), u.GetName(), err)
+			}
+		}
+
+		doc, err := sigsyaml.Marshal(u.Object)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling manifest: %w", err)
+		}
+		if !first {
+			out.WriteString("---\n")
+		}
+		first = false
+		out.Write(doc)
+	}
+
+	return out, nil
+}
+
+// transformPodSpec injects the configured imagePullSecret and applies
+// registry rewrites to the PodSpec found at path within u.
+func (p *clusterTransformPostRenderer) transformPodSpec(u *unstructured.Unstructured, path []string) error {
+	podSpec, found, err := unstructured.NestedMap(u.Object, path...)
+	if err != nil {
+		return fmt.Errorf("reading pod spec: %w", err)
+	}
+	if !found {
+		return nil
+	}
+
+	if p.imagePullSecret != "" {
+		p.appendImagePullSecret(podSpec)
+	}
+	if len(p.registryRewrites) > 0 {
+		p.rewriteContainerImages(podSpec, "containers")
+		p.rewriteContainerImages(podSpec, "initContainers")
+	}
+
+	if err := unstructured.SetNestedMap(u.Object, podSpec, path...); err != nil {
+		return fmt.Errorf("writing pod spec: %w", err)
+	}
+	return nil
+}
+
+func (p *clusterTransformPostRenderer) appendImagePullSecret(podSpec map[string]interface{}) {
+	existing, _, _ := unstructured.NestedSlice(podSpec, "imagePullSecrets")
+	for _, e := range existing {
+		if m, ok := e.(map[string]interface{}); ok && m["name"] == p.imagePullSecret {
+			return
+		}
+	}
+	existing = append(existing, map[string]interface{}{"name": p.imagePullSecret})
+	_ = unstructured.SetNestedSlice(podSpec, existing, "imagePullSecrets")
+}
+
+func (p *clusterTransformPostRenderer) rewriteContainerImages(podSpec map[string]interface{}, field string) {
+	containers, found, _ := unstructured.NestedSlice(podSpec, field)
+	if !found {
+		return
+	}
+	for i, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		image, ok := container["image"].(string)
+		if !ok {
+			continue
+		}
+		for _, rewrite := range p.registryRewrites {
+			if strings.HasPrefix(image, rewrite.From) {
+				container["image"] = rewrite.To + strings.TrimPrefix(image, rewrite.From)
+				containers[i] = container
+				break
+			}
+		}
+	}
+	_ = unstructured.SetNestedSlice(podSpec, containers, field)
+}
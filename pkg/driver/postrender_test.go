@@ -0,0 +1,287 @@
+package driver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// decodeManifests splits a multi-document YAML manifest into unstructured
+// objects for assertions, mirroring how Run itself decodes its input.
+func decodeManifests(t *testing.T, manifest string) []*unstructured.Unstructured {
+	t.Helper()
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	var objs []*unstructured.Unstructured
+	for {
+		obj := map[string]interface{}{}
+		err := decoder.Decode(&obj)
+		if err != nil {
+			break
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: obj})
+	}
+	return objs
+}
+
+func podSpecAt(t *testing.T, u *unstructured.Unstructured, path ...string) map[string]interface{} {
+	t.Helper()
+	podSpec, found, err := unstructured.NestedMap(u.Object, path...)
+	require.NoError(t, err)
+	require.True(t, found, "expected pod spec at %v", path)
+	return podSpec
+}
+
+func imageOf(t *testing.T, podSpec map[string]interface{}, field string, index int) string {
+	t.Helper()
+	containers, found, err := unstructured.NestedSlice(podSpec, field)
+	require.NoError(t, err)
+	require.True(t, found)
+	container, ok := containers[index].(map[string]interface{})
+	require.True(t, ok)
+	image, _ := container["image"].(string)
+	return image
+}
+
+func imagePullSecretNames(podSpec map[string]interface{}) []string {
+	secrets, _, _ := unstructured.NestedSlice(podSpec, "imagePullSecrets")
+	var names []string
+	for _, s := range secrets {
+		if m, ok := s.(map[string]interface{}); ok {
+			if name, ok := m["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// TestRun_PodSpecPathsByKind verifies that every Kind in podSpecPath gets its
+// imagePullSecret injected and its container images rewritten at the right
+// nested path, including CronJob's doubly-nested jobTemplate path.
+func TestRun_PodSpecPathsByKind(t *testing.T) {
+	cases := []struct {
+		kind     string
+		manifest string
+		path     []string
+	}{
+		{
+			kind: "Deployment",
+			manifest: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec", "template", "spec"},
+		},
+		{
+			kind: "StatefulSet",
+			manifest: `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec", "template", "spec"},
+		},
+		{
+			kind: "DaemonSet",
+			manifest: `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec", "template", "spec"},
+		},
+		{
+			kind: "Job",
+			manifest: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec", "template", "spec"},
+		},
+		{
+			kind: "Pod",
+			manifest: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+  - name: app
+    image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec"},
+		},
+		{
+			kind: "CronJob",
+			manifest: `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: nightly
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: app
+            image: upstream.example.com/app:1.0
+`,
+			path: []string{"spec", "jobTemplate", "spec", "template", "spec"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.kind, func(t *testing.T) {
+			p := newClusterTransformPostRenderer("regcred", []RegistryRewrite{
+				{From: "upstream.example.com", To: "mirror.local"},
+			}).(*clusterTransformPostRenderer)
+
+			out, err := p.Run(bytes.NewBufferString(tc.manifest))
+			require.NoError(t, err)
+
+			objs := decodeManifests(t, out.String())
+			require.Len(t, objs, 1)
+			podSpec := podSpecAt(t, objs[0], tc.path...)
+
+			assert.Equal(t, "mirror.local/app:1.0", imageOf(t, podSpec, "containers", 0))
+			assert.Equal(t, []string{"regcred"}, imagePullSecretNames(podSpec))
+		})
+	}
+}
+
+// TestRun_InitContainersRewritten verifies initContainers images are
+// rewritten alongside regular containers.
+func TestRun_InitContainersRewritten(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: upstream.example.com/migrate:1.0
+      containers:
+      - name: app
+        image: upstream.example.com/app:1.0
+`
+	p := newClusterTransformPostRenderer("", []RegistryRewrite{
+		{From: "upstream.example.com", To: "mirror.local"},
+	}).(*clusterTransformPostRenderer)
+
+	out, err := p.Run(bytes.NewBufferString(manifest))
+	require.NoError(t, err)
+
+	objs := decodeManifests(t, out.String())
+	require.Len(t, objs, 1)
+	podSpec := podSpecAt(t, objs[0], "spec", "template", "spec")
+
+	assert.Equal(t, "mirror.local/migrate:1.0", imageOf(t, podSpec, "initContainers", 0))
+	assert.Equal(t, "mirror.local/app:1.0", imageOf(t, podSpec, "containers", 0))
+}
+
+// TestRun_ImagePullSecretIsIdempotent verifies that running the
+// post-renderer twice over the same manifest does not append a duplicate
+// imagePullSecrets entry, and that an already-present secret of the same
+// name is left alone.
+func TestRun_ImagePullSecretIsIdempotent(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      imagePullSecrets:
+      - name: regcred
+      containers:
+      - name: app
+        image: app:1.0
+`
+	p := newClusterTransformPostRenderer("regcred", nil).(*clusterTransformPostRenderer)
+
+	out, err := p.Run(bytes.NewBufferString(manifest))
+	require.NoError(t, err)
+
+	// Running it again, as would happen on a second reconcile, must not
+	// accumulate a second entry.
+	out, err = p.Run(bytes.NewBufferString(out.String()))
+	require.NoError(t, err)
+
+	objs := decodeManifests(t, out.String())
+	require.Len(t, objs, 1)
+	podSpec := podSpecAt(t, objs[0], "spec", "template", "spec")
+
+	assert.Equal(t, []string{"regcred"}, imagePullSecretNames(podSpec))
+}
+
+// TestRun_UnrecognizedKindPassedThrough verifies resources with no known
+// PodSpec path, e.g. a ConfigMap, pass through unmodified.
+func TestRun_UnrecognizedKindPassedThrough(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+`
+	p := newClusterTransformPostRenderer("regcred", []RegistryRewrite{
+		{From: "upstream.example.com", To: "mirror.local"},
+	}).(*clusterTransformPostRenderer)
+
+	out, err := p.Run(bytes.NewBufferString(manifest))
+	require.NoError(t, err)
+
+	objs := decodeManifests(t, out.String())
+	require.Len(t, objs, 1)
+	assert.Equal(t, "ConfigMap", objs[0].GetKind())
+	data, found, err := unstructured.NestedStringMap(objs[0].Object, "data")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "value", data["key"])
+}
@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+
+	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
+)
+
+// recoverReleaseIfStuck inspects the current status of the named release
+// and, if it is in a state Helm refuses to upgrade from (pending-install,
+// pending-upgrade, pending-rollback, or failed), recovers it per
+// opts.UpgradePolicy so the subsequent upgrade can proceed. An empty
+// UpgradePolicy defaults to UpgradePolicyRollbackOnFailure. It is a no-op
+// when the release is healthy.
+//
+// The returned action is one of the RecoveryAction* constants identifying
+// what was done, or "" if the release was healthy and no recovery was
+// needed. RecoveryActionReinstall means the caller's own upgrade would be
+// redundant and should be skipped, since reinstall already applied the
+// desired chart and values.
+func (d *helmDriver) recoverReleaseIfStuck(ctx context.Context, name string, namespace string,
+	helmChart *chart.Chart, values map[string]interface{}, opts InstallOptions) (recoveryAction string, err error) {
+	upgradePolicy := opts.UpgradePolicy
+	if upgradePolicy == "" {
+		upgradePolicy = api.UpgradePolicyRollbackOnFailure
+	}
+
+	status := action.NewStatus(d.cfg)
+	rel, err := status.Run(name)
+	if err != nil {
+		return "", fmt.Errorf("getting status of release %s: %w", name, err)
+	}
+
+	switch {
+	case rel.Info.Status.IsPending():
+		return d.recoverFromPending(ctx, name, namespace, rel, helmChart, values, opts, upgradePolicy)
+	case rel.Info.Status == release.StatusFailed:
+		return d.recoverFromFailed(ctx, name, namespace, rel, helmChart, values, opts, upgradePolicy)
+	default:
+		return "", nil
+	}
+}
+
+// recoverFromPending handles a release stuck in pending-install,
+// pending-upgrade, or pending-rollback, which Helm refuses to upgrade from.
+func (d *helmDriver) recoverFromPending(ctx context.Context, name string, namespace string, rel *release.Release,
+	helmChart *chart.Chart, values map[string]interface{}, opts InstallOptions, upgradePolicy string) (string, error) {
+	switch upgradePolicy {
+	case api.UpgradePolicyFail:
+		return "", fmt.Errorf("release %s is stuck in state %s", name, rel.Info.Status)
+	case api.UpgradePolicyReinstallOnFailure:
+		return RecoveryActionReinstall, d.reinstall(ctx, name, namespace, helmChart, values, opts)
+	default: // UpgradePolicyRollbackOnFailure
+		lastDeployed, err := d.lastDeployedRevision(name)
+		if err != nil {
+			// No deployed revision exists to roll back to (e.g. the very
+			// first install never completed, or it was pruned from history
+			// by MaxHistory); fall back to reinstalling.
+			d.log.Info("no deployed revision to roll back to, reinstalling instead",
+				"release", name, "reason", err.Error())
+			return RecoveryActionReinstall, d.reinstall(ctx, name, namespace, helmChart, values, opts)
+		}
+		return RecoveryActionRollback, d.rollbackTo(ctx, name, lastDeployed)
+	}
+}
+
+// recoverFromFailed handles a release whose last revision is marked failed.
+func (d *helmDriver) recoverFromFailed(ctx context.Context, name string, namespace string, rel *release.Release,
+	helmChart *chart.Chart, values map[string]interface{}, opts InstallOptions, upgradePolicy string) (string, error) {
+	switch upgradePolicy {
+	case api.UpgradePolicyFail:
+		return "", fmt.Errorf("release %s is in state %s", name, rel.Info.Status)
+	case api.UpgradePolicyReinstallOnFailure:
+		return RecoveryActionReinstall, d.reinstall(ctx, name, namespace, helmChart, values, opts)
+	default: // UpgradePolicyRollbackOnFailure
+		lastDeployed, err := d.lastDeployedRevision(name)
+		if err != nil {
+			d.log.Info("no deployed revision to roll back to, reinstalling instead",
+				"release", name, "reason", err.Error())
+			return RecoveryActionReinstall, d.reinstall(ctx, name, namespace, helmChart, values, opts)
+		}
+		return RecoveryActionRollback, d.rollbackTo(ctx, name, lastDeployed)
+	}
+}
+
+// lastDeployedRevision returns the revision number of the most recent
+// deployed (not failed or superseded) release in name's history. Note that
+// varHelmUpgradeMaxHistory bounds how many past revisions Helm retains, so
+// on a long-stuck release the last deployed revision may already have been
+// pruned. The returned error does not tell a pruned revision apart from one
+// that never existed; callers log it and fall back to reinstalling in both
+// cases.
+func (d *helmDriver) lastDeployedRevision(name string) (int, error) {
+	history := action.NewHistory(d.cfg)
+	releases, err := history.Run(name)
+	if err != nil {
+		return 0, fmt.Errorf("getting history of release %s: %w", name, err)
+	}
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].Info.Status == release.StatusDeployed {
+			return releases[i].Version, nil
+		}
+	}
+	return 0, fmt.Errorf("release %s has no deployed revision in its retained history (max %d revisions)",
+		name, varHelmUpgradeMaxHistory)
+}
+
+// rollbackTo rolls name back to the given revision.
+func (d *helmDriver) rollbackTo(ctx context.Context, name string, revision int) error {
+	rollback := action.NewRollback(d.cfg)
+	rollback.Version = revision
+	if err := rollback.Run(name); err != nil {
+		return fmt.Errorf("rolling back release %s to revision %d: %w", name, revision, err)
+	}
+	return nil
+}
+
+// reinstall uninstalls name and installs it fresh from helmChart and
+// values, into namespace and with the same post-render and lifecycle
+// configuration opts describes for the original install.
+func (d *helmDriver) reinstall(ctx context.Context, name string, namespace string,
+	helmChart *chart.Chart, values map[string]interface{}, opts InstallOptions) error {
+	uninstall := action.NewUninstall(d.cfg)
+	if _, err := uninstall.Run(name); err != nil {
+		return fmt.Errorf("uninstalling stuck release %s: %w", name, err)
+	}
+
+	install := action.NewInstall(d.cfg)
+	install.ReleaseName = name
+	install.Namespace = namespace
+	install.PostRenderer = newClusterTransformPostRenderer(opts.ImagePullSecret, opts.RegistryRewrites)
+	applyInstallLifecycle(install, opts.Lifecycle)
+	if _, err := install.RunWithContext(ctx, helmChart, values); err != nil {
+		return fmt.Errorf("reinstalling release %s: %w", name, err)
+	}
+	return nil
+}
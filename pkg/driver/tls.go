@@ -0,0 +1,159 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	api "github.com/aws/eks-anywhere-packages/api/v1alpha1"
+)
+
+// chartRegistryTLS holds the materialized TLS trust material for a chart
+// registry, resolved from the Secrets referenced on a PackageOCISource.
+type chartRegistryTLS struct {
+	caFile             string
+	certFile           string
+	keyFile            string
+	insecureSkipVerify bool
+}
+
+// cachedTLS is a resolveTLSConfig cache entry: the materialized tls plus the
+// contentHash of the Secret bytes it was built from and the temp dir holding
+// its files, so a later call can tell whether the referenced Secrets have
+// changed and, if so, evict dir rather than trust stale material.
+type cachedTLS struct {
+	contentHash string
+	dir         string
+	tls         *chartRegistryTLS
+}
+
+// resolveTLSConfig resolves the Secrets referenced by source.TLS and writes
+// their contents to files under a temp directory so they can be handed to
+// Helm's registry client and install/upgrade actions, which take file paths
+// rather than in-memory material.
+//
+// Results are cached on the driver keyed by namespace, secret refs, and a
+// hash of the resolved Secret contents, since IsConfigChanged re-resolves
+// the same source on every reconcile via a dry-run render; without caching,
+// each of those calls would leak another temp directory. Keying on content
+// hash (rather than just the refs) means a CA/cert rotation under the same
+// Secret name is picked up on the next call instead of the driver trusting
+// stale, possibly-revoked material for the rest of the process lifetime.
+//
+// It returns a nil *chartRegistryTLS when source has no TLS configuration,
+// so callers can fall back to the ambient system trust store.
+func (d *helmDriver) resolveTLSConfig(ctx context.Context, namespace string, source api.PackageOCISource) (*chartRegistryTLS, error) {
+	if !source.HasTLSConfig() {
+		return nil, nil
+	}
+	tlsCfg := source.TLS
+
+	var caData, certData, keyData []byte
+	var err error
+	if tlsCfg.CASecretRef != "" {
+		caData, err = d.fetchSecretData(ctx, namespace, tlsCfg.CASecretRef, "ca.crt")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+	}
+	if tlsCfg.CertSecretRef != "" {
+		certData, err = d.fetchSecretData(ctx, namespace, tlsCfg.CertSecretRef, "tls.crt")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+		keyData, err = d.fetchSecretData(ctx, namespace, tlsCfg.CertSecretRef, "tls.key")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%s/%t", namespace, tlsCfg.CASecretRef, tlsCfg.CertSecretRef, tlsCfg.InsecureSkipVerify)
+	contentHash := hashTLSContent(caData, certData, keyData, tlsCfg.InsecureSkipVerify)
+
+	d.tlsCacheMu.Lock()
+	defer d.tlsCacheMu.Unlock()
+	if cached, ok := d.tlsCache[cacheKey]; ok {
+		if cached.contentHash == contentHash {
+			return cached.tls, nil
+		}
+		// The underlying Secret(s) changed since this was last resolved,
+		// e.g. a CA rotation; drop the stale material rather than keep
+		// trusting it.
+		os.RemoveAll(cached.dir)
+		delete(d.tlsCache, cacheKey)
+	}
+
+	dir, err := os.MkdirTemp("", "package-registry-tls-")
+	if err != nil {
+		return nil, fmt.Errorf("%w: creating temp dir for registry TLS material: %v", ErrGetCACertificateFailed, err)
+	}
+
+	tls := &chartRegistryTLS{insecureSkipVerify: tlsCfg.InsecureSkipVerify}
+	if caData != nil {
+		tls.caFile, err = writeTLSFile(dir, "ca.crt", caData)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+	}
+	if certData != nil {
+		tls.certFile, err = writeTLSFile(dir, "tls.crt", certData)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+		tls.keyFile, err = writeTLSFile(dir, "tls.key", keyData)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("%w: %v", ErrGetCACertificateFailed, err)
+		}
+	}
+
+	if d.tlsCache == nil {
+		d.tlsCache = map[string]*cachedTLS{}
+	}
+	d.tlsCache[cacheKey] = &cachedTLS{contentHash: contentHash, dir: dir, tls: tls}
+
+	return tls, nil
+}
+
+// fetchSecretData returns the bytes stored under dataKey in secretName.
+func (d *helmDriver) fetchSecretData(ctx context.Context, namespace, secretName, dataKey string) ([]byte, error) {
+	data, err := d.secretAuth.GetSecretData(ctx, namespace, secretName)
+	if err != nil {
+		return nil, fmt.Errorf("getting secret %s/%s: %w", namespace, secretName, err)
+	}
+	contents, ok := data[dataKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no key %q", namespace, secretName, dataKey)
+	}
+	return contents, nil
+}
+
+// writeTLSFile writes contents to fileName within dir, returning its path.
+func writeTLSFile(dir, fileName string, contents []byte) (string, error) {
+	path := filepath.Join(dir, fileName)
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// hashTLSContent returns a hex digest identifying the resolved TLS material,
+// so resolveTLSConfig can tell whether the Secrets backing a cache entry
+// have changed since it was last resolved.
+func hashTLSContent(caData, certData, keyData []byte, insecureSkipVerify bool) string {
+	h := sha256.New()
+	h.Write(caData)
+	h.Write([]byte{0})
+	h.Write(certData)
+	h.Write([]byte{0})
+	h.Write(keyData)
+	if insecureSkipVerify {
+		h.Write([]byte{1})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}